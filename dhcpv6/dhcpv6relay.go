@@ -124,15 +124,7 @@ func (r *DHCPv6Relay) AddOption(option Option) {
 
 // UpdateOption replaces the first option of the same type as the specified one.
 func (r *DHCPv6Relay) UpdateOption(option Option) {
-	for idx, opt := range r.options {
-		if opt.Code() == option.Code() {
-			r.options[idx] = option
-			// don't look further
-			return
-		}
-	}
-	// if not found, add it
-	r.AddOption(option)
+	r.options = UpdateOptions(r.options, option)
 }
 
 func (r *DHCPv6Relay) IsRelay() bool {
@@ -0,0 +1,123 @@
+package dhcpv6
+
+// UpdateOptions replaces the first option in options that shares a code
+// with option, or appends it if none matched. It is the shared
+// implementation behind DHCPv6Relay.UpdateOption and OptionSet.Set, so
+// both apply the exact same "replace first match, else append" semantics.
+// The UpdateOption method of non-relay messages does not call it.
+func UpdateOptions(options []Option, option Option) []Option {
+	for idx, opt := range options {
+		if opt.Code() == option.Code() {
+			options[idx] = option
+			return options
+		}
+	}
+	return append(options, option)
+}
+
+// SafeDefaultOptionCodes are option codes a server may hand out in a reply
+// even when the client didn't list them in its Option Request Option
+// (ORO). RFC 8415 leaves inclusion of most options to server policy, but a
+// handful - name resolution and the search list - are commonly expected
+// regardless of ORO. Every other default option is only merged in when
+// the client actually asked for it.
+var SafeDefaultOptionCodes = map[OptionCode]bool{
+	OPTION_DNS_RECURSIVE_NAME_SERVER: true,
+	OPTION_DOMAIN_SEARCH_LIST:        true,
+}
+
+// OptionSet is an ordered collection of options keyed by OptionCode, with
+// at most one option per code. It is used to hold a server's configured
+// default options, and the per-client overrides that take precedence over
+// them.
+type OptionSet struct {
+	options []Option
+}
+
+// NewOptionSet builds an OptionSet from the given options. If two options
+// share a code, the later one wins.
+func NewOptionSet(opts ...Option) *OptionSet {
+	s := &OptionSet{}
+	for _, opt := range opts {
+		s.Set(opt)
+	}
+	return s
+}
+
+// Set adds option to the set, replacing any existing option of the same
+// code.
+func (s *OptionSet) Set(option Option) {
+	s.options = UpdateOptions(s.options, option)
+}
+
+// Get returns the option with the given code, or nil if the set has none.
+func (s *OptionSet) Get(code OptionCode) Option {
+	return getOption(s.options, code)
+}
+
+// Options returns the options held by the set, in insertion order.
+func (s *OptionSet) Options() []Option {
+	return s.options
+}
+
+// RequestedOptionCodes extracts the list of option codes a client asked
+// for via its Option Request Option (OPTION_ORO), if present.
+func RequestedOptionCodes(msg DHCPv6) []OptionCode {
+	oro, ok := msg.GetOneOption(OPTION_ORO).(optionRequestOption)
+	if !ok {
+		return nil
+	}
+	return oro.RequestedOptions()
+}
+
+// optionRequestOption is satisfied by the OPTION_ORO option implementation.
+// It is declared locally so this file doesn't need to know the concrete
+// type the rest of the package uses to represent it.
+type optionRequestOption interface {
+	RequestedOptions() []OptionCode
+}
+
+// MergeDefaultOptions merges the set's options into msg following server
+// policy:
+//
+//   - an option msg already carries is left untouched, the message wins;
+//   - a default in SafeDefaultOptionCodes is always added to fill the gap;
+//   - any other default is only added if the client requested its code via
+//     ORO.
+//
+// overrides, if non-nil, is applied unconditionally - it exists precisely
+// to target a specific client with a value regardless of what that client
+// put in its ORO - and takes precedence over defaults, which remain
+// subject to the SafeDefaultOptionCodes/ORO gate above.
+func MergeDefaultOptions(msg DHCPv6, defaults, overrides *OptionSet) {
+	if overrides != nil {
+		for _, opt := range overrides.Options() {
+			if msg.GetOneOption(opt.Code()) != nil {
+				continue
+			}
+			msg.UpdateOption(opt)
+		}
+	}
+
+	if defaults != nil {
+		requested := RequestedOptionCodes(msg)
+		for _, opt := range defaults.Options() {
+			if msg.GetOneOption(opt.Code()) != nil {
+				continue
+			}
+			if !SafeDefaultOptionCodes[opt.Code()] && !containsCode(requested, opt.Code()) {
+				continue
+			}
+			msg.UpdateOption(opt)
+		}
+	}
+}
+
+func containsCode(codes []OptionCode, code OptionCode) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
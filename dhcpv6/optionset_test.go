@@ -0,0 +1,143 @@
+package dhcpv6
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// testOption is a minimal Option implementation used by tests that don't
+// care about any particular option's wire format.
+type testOption struct {
+	code OptionCode
+	data []byte
+}
+
+func (o testOption) Code() OptionCode { return o.code }
+func (o testOption) Length() int      { return len(o.data) }
+func (o testOption) String() string   { return "testOption" }
+
+func (o testOption) ToBytes() []byte {
+	b := make([]byte, 4+len(o.data))
+	binary.BigEndian.PutUint16(b[0:2], uint16(o.code))
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(o.data)))
+	copy(b[4:], o.data)
+	return b
+}
+
+// testOROOption implements optionRequestOption so it satisfies
+// RequestedOptionCodes' type assertion.
+type testOROOption struct {
+	testOption
+	requested []OptionCode
+}
+
+func (o testOROOption) RequestedOptions() []OptionCode { return o.requested }
+
+func opt(code OptionCode, data string) Option {
+	return testOption{code: code, data: []byte(data)}
+}
+
+func TestUpdateOptionsReplacesExisting(t *testing.T) {
+	options := []Option{opt(OPTION_BOOTFILE_URL, "old")}
+	options = UpdateOptions(options, opt(OPTION_BOOTFILE_URL, "new"))
+
+	if len(options) != 1 {
+		t.Fatalf("got %d options, want 1", len(options))
+	}
+	if got := string(options[0].(testOption).data); got != "new" {
+		t.Errorf("got data %q, want %q", got, "new")
+	}
+}
+
+func TestUpdateOptionsAppendsNew(t *testing.T) {
+	options := []Option{opt(OPTION_BOOTFILE_URL, "url")}
+	options = UpdateOptions(options, opt(OPTION_NTP_SERVER, "ntp"))
+
+	if len(options) != 2 {
+		t.Fatalf("got %d options, want 2", len(options))
+	}
+}
+
+func TestOptionSetSetGet(t *testing.T) {
+	s := NewOptionSet(opt(OPTION_BOOTFILE_URL, "first"))
+	s.Set(opt(OPTION_BOOTFILE_URL, "second"))
+	s.Set(opt(OPTION_NTP_SERVER, "ntp"))
+
+	if got := s.Get(OPTION_BOOTFILE_URL); got == nil || string(got.(testOption).data) != "second" {
+		t.Errorf("got %v, want bootfile url option with data %q", got, "second")
+	}
+	if s.Get(OPTION_DNS_RECURSIVE_NAME_SERVER) != nil {
+		t.Errorf("expected no DNS option in the set")
+	}
+}
+
+func TestMergeDefaultOptionsMessageWins(t *testing.T) {
+	msg := &DHCPv6Relay{}
+	msg.AddOption(opt(OPTION_DNS_RECURSIVE_NAME_SERVER, "client-set"))
+	defaults := NewOptionSet(opt(OPTION_DNS_RECURSIVE_NAME_SERVER, "default"))
+
+	MergeDefaultOptions(msg, defaults, nil)
+
+	got := msg.GetOneOption(OPTION_DNS_RECURSIVE_NAME_SERVER).(testOption)
+	if string(got.data) != "client-set" {
+		t.Errorf("got %q, want the message's own option to win", got.data)
+	}
+}
+
+func TestMergeDefaultOptionsSafeDefaultAlwaysApplied(t *testing.T) {
+	msg := &DHCPv6Relay{}
+	defaults := NewOptionSet(opt(OPTION_DNS_RECURSIVE_NAME_SERVER, "default-dns"))
+
+	MergeDefaultOptions(msg, defaults, nil)
+
+	got := msg.GetOneOption(OPTION_DNS_RECURSIVE_NAME_SERVER)
+	if got == nil || string(got.(testOption).data) != "default-dns" {
+		t.Errorf("got %v, want the safe default to be merged in unconditionally", got)
+	}
+}
+
+func TestMergeDefaultOptionsNonSafeRequiresORO(t *testing.T) {
+	defaults := NewOptionSet(opt(OPTION_BOOTFILE_URL, "tftp://boot"))
+
+	withoutORO := &DHCPv6Relay{}
+	MergeDefaultOptions(withoutORO, defaults, nil)
+	if withoutORO.GetOneOption(OPTION_BOOTFILE_URL) != nil {
+		t.Errorf("bootfile url should not be added when not requested via ORO")
+	}
+
+	withORO := &DHCPv6Relay{}
+	withORO.AddOption(testOROOption{
+		testOption: testOption{code: OPTION_ORO},
+		requested:  []OptionCode{OPTION_BOOTFILE_URL},
+	})
+	MergeDefaultOptions(withORO, defaults, nil)
+	got := withORO.GetOneOption(OPTION_BOOTFILE_URL)
+	if got == nil || string(got.(testOption).data) != "tftp://boot" {
+		t.Errorf("got %v, want bootfile url merged in once requested via ORO", got)
+	}
+}
+
+func TestMergeDefaultOptionsOverrideIsUnconditional(t *testing.T) {
+	msg := &DHCPv6Relay{}
+	defaults := NewOptionSet(opt(OPTION_BOOTFILE_URL, "default-url"))
+	overrides := NewOptionSet(opt(OPTION_BOOTFILE_URL, "override-url"))
+
+	// No ORO on msg: the non-safe default would normally be dropped, but
+	// the targeted per-client override must still apply.
+	MergeDefaultOptions(msg, defaults, overrides)
+
+	got := msg.GetOneOption(OPTION_BOOTFILE_URL)
+	if got == nil || string(got.(testOption).data) != "override-url" {
+		t.Errorf("got %v, want override to win regardless of ORO", got)
+	}
+}
+
+func TestContainsCode(t *testing.T) {
+	codes := []OptionCode{OPTION_BOOTFILE_URL, OPTION_NTP_SERVER}
+	if !containsCode(codes, OPTION_NTP_SERVER) {
+		t.Errorf("expected codes to contain OPTION_NTP_SERVER")
+	}
+	if containsCode(codes, OPTION_DNS_RECURSIVE_NAME_SERVER) {
+		t.Errorf("expected codes not to contain OPTION_DNS_RECURSIVE_NAME_SERVER")
+	}
+}
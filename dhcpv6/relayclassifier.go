@@ -0,0 +1,107 @@
+package dhcpv6
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// optionData returns the raw value bytes of an option, stripping the
+// 2-byte code and 2-byte length header that Option.ToBytes() includes.
+func optionData(opt Option) []byte {
+	if opt == nil {
+		return nil
+	}
+	b := opt.ToBytes()
+	if len(b) <= 4 {
+		return nil
+	}
+	return b[4:]
+}
+
+// InterfaceID returns the content of the relay's OPTION_INTERFACE_ID, or
+// nil if the relay didn't set one.
+func (r *DHCPv6Relay) InterfaceID() []byte {
+	return optionData(r.GetOneOption(OPTION_INTERFACE_ID))
+}
+
+// RemoteID returns the enterprise number and remote identifier carried by
+// the relay's OPTION_REMOTE_ID (RFC 4649). It returns a nil id if the
+// relay didn't set one.
+func (r *DHCPv6Relay) RemoteID() (enterpriseNum uint32, id []byte) {
+	data := optionData(r.GetOneOption(OPTION_REMOTE_ID))
+	if len(data) < 4 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint32(data[0:4]), data[4:]
+}
+
+// SubscriberID returns the content of the relay's OPTION_SUBSCRIBER_ID
+// (RFC 4580), or nil if the relay didn't set one.
+func (r *DHCPv6Relay) SubscriberID() []byte {
+	return optionData(r.GetOneOption(OPTION_SUBSCRIBER_ID))
+}
+
+// RelayClassifier derives a subscriber key - used for policy lookup such
+// as lease-pool selection, option overrides, or rate limiting - from a
+// client's relay chain. It is given both the outermost relay (the one the
+// server received) and the innermost one (the one closest to the
+// client), since deployments differ in which end of the chain carries
+// identifying information.
+type RelayClassifier interface {
+	Classify(outermost, innermost *DHCPv6Relay) (key string, err error)
+}
+
+// SubscriberKey builds a canonical string key from a Remote-ID and
+// Interface-ID pair, suitable for indexing leases by physical port on an
+// access concentrator.
+func SubscriberKey(enterpriseNum uint32, remoteID, interfaceID []byte) string {
+	return fmt.Sprintf("%d:%x:%x", enterpriseNum, remoteID, interfaceID)
+}
+
+// RemoteInterfaceClassifier is a RelayClassifier that keys subscribers by
+// the Remote-ID and Interface-ID options on the relay closest to the
+// client, as stamped by an access concentrator (RFC 4649, RFC 4580).
+type RemoteInterfaceClassifier struct{}
+
+// Classify implements RelayClassifier.
+func (RemoteInterfaceClassifier) Classify(outermost, innermost *DHCPv6Relay) (string, error) {
+	if innermost == nil {
+		return "", errors.New("dhcpv6: no relay to classify")
+	}
+	enterpriseNum, remoteID := innermost.RemoteID()
+	if remoteID == nil {
+		return "", errors.New("dhcpv6: relay has no Remote-ID option")
+	}
+	return SubscriberKey(enterpriseNum, remoteID, innermost.InterfaceID()), nil
+}
+
+// ClassifySubscriber decapsulates relayForw down to the client message and
+// its peer address in a single pass over the relay chain, then runs
+// classifier against it, so a handler can obtain both the client identity
+// and the access-network context in one call.
+func ClassifySubscriber(relayForw *DHCPv6Relay, classifier RelayClassifier) (msg DHCPv6, peer net.IP, key string, err error) {
+	innermost := relayForw
+	var cur DHCPv6 = relayForw
+	for {
+		next, derr := DecapsulateRelay(cur)
+		if derr != nil {
+			return nil, nil, "", derr
+		}
+		nextRelay, ok := next.(*DHCPv6Relay)
+		if !ok {
+			msg = next
+			break
+		}
+		innermost = nextRelay
+		cur = next
+	}
+	peer = innermost.PeerAddr()
+
+	key, err = classifier.Classify(relayForw, innermost)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return msg, peer, key, nil
+}
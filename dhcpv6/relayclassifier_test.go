@@ -0,0 +1,187 @@
+package dhcpv6
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func relayWithOption(o Option) *DHCPv6Relay {
+	r := &DHCPv6Relay{}
+	r.AddOption(o)
+	return r
+}
+
+func remoteIDData(enterpriseNum uint32, id string) string {
+	b := make([]byte, 4+len(id))
+	binary.BigEndian.PutUint32(b[0:4], enterpriseNum)
+	copy(b[4:], id)
+	return string(b)
+}
+
+func TestOptionDataStripsHeader(t *testing.T) {
+	o := opt(OPTION_INTERFACE_ID, "port-1")
+	if got := string(optionData(o)); got != "port-1" {
+		t.Errorf("got %q, want %q", got, "port-1")
+	}
+}
+
+func TestOptionDataNilForMissingOrEmptyOption(t *testing.T) {
+	if got := optionData(nil); got != nil {
+		t.Errorf("got %v, want nil for a missing option", got)
+	}
+	if got := optionData(opt(OPTION_INTERFACE_ID, "")); got != nil {
+		t.Errorf("got %v, want nil for an empty-payload option", got)
+	}
+}
+
+func TestRelayInterfaceID(t *testing.T) {
+	r := relayWithOption(opt(OPTION_INTERFACE_ID, "eth0.100"))
+	if got := string(r.InterfaceID()); got != "eth0.100" {
+		t.Errorf("got %q, want %q", got, "eth0.100")
+	}
+	if got := (&DHCPv6Relay{}).InterfaceID(); got != nil {
+		t.Errorf("got %v, want nil when no Interface-ID was set", got)
+	}
+}
+
+func TestRelayRemoteID(t *testing.T) {
+	r := relayWithOption(opt(OPTION_REMOTE_ID, remoteIDData(12345, "circuit-42")))
+	enterpriseNum, id := r.RemoteID()
+	if enterpriseNum != 12345 || string(id) != "circuit-42" {
+		t.Errorf("got (%d, %q), want (12345, %q)", enterpriseNum, id, "circuit-42")
+	}
+}
+
+func TestRelayRemoteIDTruncated(t *testing.T) {
+	// Fewer than the 4 bytes needed for the enterprise number: treated the
+	// same as no Remote-ID at all, rather than panicking.
+	r := relayWithOption(opt(OPTION_REMOTE_ID, "ab"))
+	enterpriseNum, id := r.RemoteID()
+	if enterpriseNum != 0 || id != nil {
+		t.Errorf("got (%d, %q), want (0, nil) for truncated data", enterpriseNum, id)
+	}
+}
+
+func TestRelayRemoteIDMissing(t *testing.T) {
+	enterpriseNum, id := (&DHCPv6Relay{}).RemoteID()
+	if enterpriseNum != 0 || id != nil {
+		t.Errorf("got (%d, %q), want (0, nil) when no Remote-ID was set", enterpriseNum, id)
+	}
+}
+
+func TestRelaySubscriberID(t *testing.T) {
+	r := relayWithOption(opt(OPTION_SUBSCRIBER_ID, "subscriber-7"))
+	if got := string(r.SubscriberID()); got != "subscriber-7" {
+		t.Errorf("got %q, want %q", got, "subscriber-7")
+	}
+}
+
+func TestSubscriberKey(t *testing.T) {
+	got := SubscriberKey(12345, []byte("remote"), []byte("iface"))
+	if got == "" {
+		t.Fatalf("SubscriberKey returned an empty key")
+	}
+	// Same inputs must always produce the same key, and differing inputs
+	// must not collide.
+	if got != SubscriberKey(12345, []byte("remote"), []byte("iface")) {
+		t.Errorf("SubscriberKey is not deterministic for identical inputs")
+	}
+	if got == SubscriberKey(12346, []byte("remote"), []byte("iface")) {
+		t.Errorf("SubscriberKey collided across different enterprise numbers")
+	}
+	if got == SubscriberKey(12345, []byte("other"), []byte("iface")) {
+		t.Errorf("SubscriberKey collided across different remote IDs")
+	}
+}
+
+func TestRemoteInterfaceClassifierClassify(t *testing.T) {
+	innermost := &DHCPv6Relay{}
+	innermost.AddOption(opt(OPTION_REMOTE_ID, remoteIDData(99, "port-a")))
+	innermost.AddOption(opt(OPTION_INTERFACE_ID, "eth0.200"))
+	outermost := &DHCPv6Relay{}
+
+	var c RemoteInterfaceClassifier
+	key, err := c.Classify(outermost, innermost)
+	if err != nil {
+		t.Fatalf("Classify returned an error: %v", err)
+	}
+	want := SubscriberKey(99, []byte("port-a"), []byte("eth0.200"))
+	if key != want {
+		t.Errorf("got key %q, want %q", key, want)
+	}
+}
+
+func TestRemoteInterfaceClassifierClassifyNoRemoteID(t *testing.T) {
+	var c RemoteInterfaceClassifier
+	if _, err := c.Classify(&DHCPv6Relay{}, &DHCPv6Relay{}); err == nil {
+		t.Errorf("expected an error when the relay has no Remote-ID option")
+	}
+}
+
+func TestRemoteInterfaceClassifierClassifyNilInnermost(t *testing.T) {
+	var c RemoteInterfaceClassifier
+	if _, err := c.Classify(&DHCPv6Relay{}, nil); err == nil {
+		t.Errorf("expected an error when there is no innermost relay")
+	}
+}
+
+// buildRelayChain assembles a 2-hop relay chain - an outer relay wrapping
+// an inner relay wrapping a client SOLICIT - using the package's own
+// encapsulation helpers, so the test exercises the same relay nesting
+// ClassifySubscriber walks in production. The chain is built in memory
+// and is not serialized.
+func buildRelayChain(t *testing.T, innerPeer, outerPeer net.IP) *DHCPv6Relay {
+	t.Helper()
+
+	clientMsg, err := FromBytes([]byte{byte(SOLICIT), 0x00, 0x00, 0x01})
+	if err != nil {
+		t.Fatalf("FromBytes(client message): %v", err)
+	}
+
+	inner, err := EncapsulateRelay(clientMsg, RELAY_FORW, net.ParseIP("fe80::1"), innerPeer)
+	if err != nil {
+		t.Fatalf("EncapsulateRelay(inner): %v", err)
+	}
+	innerRelay := inner.(*DHCPv6Relay)
+	innerRelay.AddOption(opt(OPTION_REMOTE_ID, remoteIDData(99, "port-a")))
+	innerRelay.AddOption(opt(OPTION_INTERFACE_ID, "eth0.200"))
+
+	outer, err := EncapsulateRelay(innerRelay, RELAY_FORW, net.ParseIP("fe80::3"), outerPeer)
+	if err != nil {
+		t.Fatalf("EncapsulateRelay(outer): %v", err)
+	}
+	return outer.(*DHCPv6Relay)
+}
+
+func TestClassifySubscriberMultiHop(t *testing.T) {
+	innerPeer := net.ParseIP("fe80::2")
+	outerPeer := net.ParseIP("fe80::4")
+	outerRelay := buildRelayChain(t, innerPeer, outerPeer)
+
+	msg, peer, key, err := ClassifySubscriber(outerRelay, RemoteInterfaceClassifier{})
+	if err != nil {
+		t.Fatalf("ClassifySubscriber: %v", err)
+	}
+	if msg.Type() != SOLICIT {
+		t.Errorf("got message type %v, want SOLICIT", msg.Type())
+	}
+	if !peer.Equal(innerPeer) {
+		t.Errorf("got peer %v, want the innermost relay's peer %v", peer, innerPeer)
+	}
+	want := SubscriberKey(99, []byte("port-a"), []byte("eth0.200"))
+	if key != want {
+		t.Errorf("got key %q, want %q", key, want)
+	}
+}
+
+func TestClassifySubscriberDecapsulateError(t *testing.T) {
+	// A relay with no embedded RELAY_MSG option is malformed: there is
+	// nothing to decapsulate.
+	relay := &DHCPv6Relay{}
+	relay.SetMessageType(RELAY_FORW)
+
+	if _, _, _, err := ClassifySubscriber(relay, RemoteInterfaceClassifier{}); err == nil {
+		t.Errorf("expected an error decapsulating a relay with no embedded message")
+	}
+}
@@ -0,0 +1,299 @@
+// Package server6 implements a DHCPv6 relay-agent server built on top of
+// package dhcpv6. It listens for RELAY_FORW messages on one or more
+// interfaces, hands the decapsulated client request to a user-supplied
+// Handler, and wraps the Handler's reply back through the same relay
+// chain the request arrived on before sending it out.
+//
+// A minimal relay looks like:
+//
+//	srv, err := server6.NewServer(handler, server6.InterfaceConfig{Name: "eth0"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	log.Fatal(srv.ListenAndServe())
+package server6
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv6"
+
+	"github.com/xgfone/dhcp/dhcpv6"
+)
+
+// MaxHopCount is the largest hop-count a RELAY_FORW message may carry
+// before the server refuses to process it (RFC 3315, Section 7).
+const MaxHopCount = 32
+
+// MaxDatagramSize is the size of the read buffer used for incoming
+// packets. DHCPv6 relay chains can nest several OPTION_RELAY_MSG options,
+// so a generous buffer is used instead of the single-message default.
+const MaxDatagramSize = 4096
+
+// UpstreamReplyTimeout bounds how long forward waits for an upstream
+// server to answer a forwarded RELAY_FORW before giving up.
+const UpstreamReplyTimeout = 5 * time.Second
+
+// DefaultAddr is the link-local multicast group and port DHCPv6 relay
+// agents and servers listen on (All_DHCP_Relay_Agents_and_Servers,
+// RFC 3315 Section 5.1).
+var DefaultAddr = &net.UDPAddr{IP: net.ParseIP("ff02::1:2"), Port: 547}
+
+// Handler processes a decapsulated client request and returns the message
+// to relay back, or a nil message to drop the request without replying.
+// peer is the innermost relay peer address, i.e. the client's address.
+type Handler func(ctx context.Context, req dhcpv6.DHCPv6, peer net.IP) (dhcpv6.DHCPv6, error)
+
+// InterfaceConfig describes one interface the Server listens on.
+type InterfaceConfig struct {
+	// Name is the network interface to join the multicast group on.
+	Name string
+
+	// Addr is the local address to listen on. It defaults to DefaultAddr.
+	Addr *net.UDPAddr
+
+	// Upstream, if set, is a unicast DHCPv6 server address that inbound
+	// RELAY_FORW messages are forwarded to as-is, instead of
+	// being answered locally by Handler.
+	Upstream *net.UDPAddr
+}
+
+// Server is a DHCPv6 relay-agent server. It joins the relay multicast
+// group on one or more interfaces, decodes incoming RELAY_FORW packets,
+// and relays replies produced either by Handler or by an upstream server.
+type Server struct {
+	Handler Handler
+
+	// DefaultOptions, if set, is merged into every reply before it is
+	// sent, following the policy documented on dhcpv6.MergeDefaultOptions.
+	DefaultOptions *dhcpv6.OptionSet
+
+	// ClientOverrides, if set, supplies per-client default options that
+	// take precedence over DefaultOptions. It is keyed by the client's
+	// DUID, as returned by DUIDKey.
+	ClientOverrides map[string]*dhcpv6.OptionSet
+
+	ifaces []InterfaceConfig
+
+	mu    sync.Mutex
+	conns []*ipv6.PacketConn
+	done  chan struct{}
+}
+
+// DUIDKey returns the map key ClientOverrides is indexed by for a given
+// client DUID, found via msg.GetOneOption(dhcpv6.OPTION_CLIENTID).
+func DUIDKey(duid dhcpv6.Option) string {
+	return string(duid.ToBytes())
+}
+
+// NewServer creates a Server that will invoke handler for every client
+// request received on the given interfaces. At least one interface must
+// be given.
+func NewServer(handler Handler, ifaces ...InterfaceConfig) (*Server, error) {
+	if handler == nil {
+		return nil, errors.New("server6: handler cannot be nil")
+	}
+	if len(ifaces) == 0 {
+		return nil, errors.New("server6: at least one interface is required")
+	}
+	return &Server{
+		Handler: handler,
+		ifaces:  ifaces,
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// ListenAndServe joins the relay multicast group on every configured
+// interface and serves requests until Shutdown is called or an
+// unrecoverable error occurs.
+func (s *Server) ListenAndServe() error {
+	errs := make(chan error, len(s.ifaces))
+	for _, cfg := range s.ifaces {
+		conn, err := listen(cfg)
+		if err != nil {
+			s.Shutdown()
+			return fmt.Errorf("server6: listen on %q: %w", cfg.Name, err)
+		}
+		s.addConn(conn)
+
+		cfg := cfg
+		go func() {
+			errs <- s.serve(cfg, conn)
+		}()
+	}
+	err := <-errs
+	// A single interface's listener dying (for any reason other than a
+	// user-initiated Shutdown) is a fatal error for the server as a
+	// whole: tear every other interface down too, rather than leaving
+	// them running with no way for the caller to know they're orphaned.
+	s.Shutdown()
+	return err
+}
+
+// addConn registers conn so Shutdown closes it.
+func (s *Server) addConn(conn *ipv6.PacketConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns = append(s.conns, conn)
+}
+
+// Shutdown closes every listening connection, causing ListenAndServe to
+// return. It is safe to call more than once.
+func (s *Server) Shutdown() error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+		close(s.done)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for _, conn := range s.conns {
+		if cerr := conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func listen(cfg InterfaceConfig) (*ipv6.PacketConn, error) {
+	addr := cfg.Addr
+	if addr == nil {
+		addr = DefaultAddr
+	}
+	iface, err := net.InterfaceByName(cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	udpConn, err := net.ListenUDP("udp6", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := ipv6.NewPacketConn(udpConn)
+	if err := conn.JoinGroup(iface, &net.UDPAddr{IP: addr.IP}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (s *Server) serve(cfg InterfaceConfig, conn *ipv6.PacketConn) error {
+	buf := make([]byte, MaxDatagramSize)
+	for {
+		n, _, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+				return err
+			}
+		}
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		go s.handle(cfg, conn, data, peer)
+	}
+}
+
+// decodeRelayForw decodes data as a RELAY_FORW message, rejecting anything
+// that isn't a well-formed relay-agent request within MaxHopCount. It has
+// no side effects, so it's separated out from handle() to be unit-tested
+// without a real socket.
+func decodeRelayForw(data []byte) (*dhcpv6.DHCPv6Relay, error) {
+	msg, err := dhcpv6.FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	relayForw, ok := msg.(*dhcpv6.DHCPv6Relay)
+	if !ok || relayForw.Type() != dhcpv6.RELAY_FORW {
+		return nil, errors.New("server6: not a RELAY_FORW message")
+	}
+	if relayForw.HopCount() > MaxHopCount {
+		return nil, fmt.Errorf("server6: hop count %d exceeds MaxHopCount (%d)", relayForw.HopCount(), MaxHopCount)
+	}
+	return relayForw, nil
+}
+
+func (s *Server) handle(cfg InterfaceConfig, conn *ipv6.PacketConn, data []byte, peer net.Addr) {
+	relayForw, err := decodeRelayForw(data)
+	if err != nil {
+		// only well-formed relay-agent traffic within the hop-count limit
+		// is handled here
+		return
+	}
+
+	if cfg.Upstream != nil {
+		s.forward(conn, relayForw, peer, cfg.Upstream)
+		return
+	}
+
+	req, err := relayForw.GetInnerMessage()
+	if err != nil {
+		return
+	}
+	clientAddr, err := relayForw.GetInnerPeerAddr()
+	if err != nil {
+		return
+	}
+
+	reply, err := s.Handler(context.Background(), req, clientAddr)
+	if err != nil || reply == nil {
+		return
+	}
+	dhcpv6.MergeDefaultOptions(reply, s.DefaultOptions, s.overridesFor(req))
+
+	relayRepl, err := dhcpv6.NewRelayReplFromRelayForw(relayForw, reply)
+	if err != nil {
+		return
+	}
+	conn.WriteTo(relayRepl.ToBytes(), nil, peer)
+}
+
+// overridesFor looks up the per-client default options for req's DUID in
+// ClientOverrides, returning nil if there is no override table or no
+// client identifier on the request.
+func (s *Server) overridesFor(req dhcpv6.DHCPv6) *dhcpv6.OptionSet {
+	if s.ClientOverrides == nil {
+		return nil
+	}
+	clientID := req.GetOneOption(dhcpv6.OPTION_CLIENTID)
+	if clientID == nil {
+		return nil
+	}
+	return s.ClientOverrides[DUIDKey(clientID)]
+}
+
+// forward relays a RELAY_FORW packet to an upstream unicast server
+// verbatim, then waits for the matching RELAY_REPL and sends it back to
+// the downstream peer it was received from.
+func (s *Server) forward(conn *ipv6.PacketConn, relayForw *dhcpv6.DHCPv6Relay, downstream net.Addr, upstream *net.UDPAddr) {
+	upConn, err := net.DialUDP("udp6", nil, upstream)
+	if err != nil {
+		return
+	}
+	defer upConn.Close()
+
+	if _, err := upConn.Write(relayForw.ToBytes()); err != nil {
+		return
+	}
+	if err := upConn.SetReadDeadline(time.Now().Add(UpstreamReplyTimeout)); err != nil {
+		return
+	}
+
+	buf := make([]byte, MaxDatagramSize)
+	n, err := upConn.Read(buf)
+	if err != nil {
+		return
+	}
+	reply, err := dhcpv6.FromBytes(buf[:n])
+	if err != nil || reply.Type() != dhcpv6.RELAY_REPL {
+		return
+	}
+	conn.WriteTo(reply.ToBytes(), nil, downstream)
+}
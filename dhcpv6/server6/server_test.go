@@ -0,0 +1,147 @@
+package server6
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/xgfone/dhcp/dhcpv6"
+)
+
+func noopHandler(ctx context.Context, req dhcpv6.DHCPv6, peer net.IP) (dhcpv6.DHCPv6, error) {
+	return nil, nil
+}
+
+// fakeOption is a minimal dhcpv6.Option implementation for tests that
+// don't need any particular option's wire format.
+type fakeOption struct {
+	code dhcpv6.OptionCode
+	data []byte
+}
+
+func (o fakeOption) Code() dhcpv6.OptionCode { return o.code }
+func (o fakeOption) Length() int             { return len(o.data) }
+func (o fakeOption) String() string          { return "fakeOption" }
+
+func (o fakeOption) ToBytes() []byte {
+	b := make([]byte, 4+len(o.data))
+	binary.BigEndian.PutUint16(b[0:2], uint16(o.code))
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(o.data)))
+	copy(b[4:], o.data)
+	return b
+}
+
+func clientID(duid string) dhcpv6.Option {
+	return fakeOption{code: dhcpv6.OPTION_CLIENTID, data: []byte(duid)}
+}
+
+func TestNewServerRequiresHandler(t *testing.T) {
+	if _, err := NewServer(nil, InterfaceConfig{Name: "lo"}); err == nil {
+		t.Errorf("expected an error for a nil handler")
+	}
+}
+
+func TestNewServerRequiresAtLeastOneInterface(t *testing.T) {
+	if _, err := NewServer(noopHandler); err == nil {
+		t.Errorf("expected an error when no interfaces are given")
+	}
+}
+
+func TestNewServerOK(t *testing.T) {
+	srv, err := NewServer(noopHandler, InterfaceConfig{Name: "lo"})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if srv.Handler == nil {
+		t.Errorf("expected Handler to be set")
+	}
+}
+
+func TestDUIDKey(t *testing.T) {
+	a := clientID("duid-a")
+	sameAsA := clientID("duid-a")
+	b := clientID("duid-b")
+
+	if DUIDKey(a) != DUIDKey(sameAsA) {
+		t.Errorf("DUIDKey should be deterministic for identical DUIDs")
+	}
+	if DUIDKey(a) == DUIDKey(b) {
+		t.Errorf("DUIDKey should differ for different DUIDs")
+	}
+}
+
+func TestServerOverridesFor(t *testing.T) {
+	srv, err := NewServer(noopHandler, InterfaceConfig{Name: "lo"})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	req := &dhcpv6.DHCPv6Relay{}
+	if got := srv.overridesFor(req); got != nil {
+		t.Errorf("got %v, want nil when ClientOverrides is unset", got)
+	}
+
+	req.AddOption(clientID("duid-a"))
+	srv.ClientOverrides = map[string]*dhcpv6.OptionSet{
+		DUIDKey(clientID("duid-b")): dhcpv6.NewOptionSet(),
+	}
+	if got := srv.overridesFor(req); got != nil {
+		t.Errorf("got %v, want nil when the client has no override entry", got)
+	}
+
+	want := dhcpv6.NewOptionSet()
+	srv.ClientOverrides = map[string]*dhcpv6.OptionSet{
+		DUIDKey(clientID("duid-a")): want,
+	}
+	if got := srv.overridesFor(req); got != want {
+		t.Errorf("got %v, want the override set registered for the client's DUID", got)
+	}
+}
+
+// buildRelayForw constructs a one-hop RELAY_FORW wrapping a client SOLICIT,
+// using the package's own encapsulation helpers, with hopCount forced to
+// the given value.
+func buildRelayForw(t *testing.T, hopCount uint8) *dhcpv6.DHCPv6Relay {
+	t.Helper()
+
+	clientMsg, err := dhcpv6.FromBytes([]byte{byte(dhcpv6.SOLICIT), 0x00, 0x00, 0x01})
+	if err != nil {
+		t.Fatalf("FromBytes(client message): %v", err)
+	}
+	relay, err := dhcpv6.EncapsulateRelay(clientMsg, dhcpv6.RELAY_FORW, net.ParseIP("fe80::1"), net.ParseIP("fe80::2"))
+	if err != nil {
+		t.Fatalf("EncapsulateRelay: %v", err)
+	}
+	r := relay.(*dhcpv6.DHCPv6Relay)
+	r.SetHopCount(hopCount)
+	return r
+}
+
+func TestDecodeRelayForwAcceptsValidHopCount(t *testing.T) {
+	relay := buildRelayForw(t, MaxHopCount)
+
+	got, err := decodeRelayForw(relay.ToBytes())
+	if err != nil {
+		t.Fatalf("decodeRelayForw: %v", err)
+	}
+	if got.HopCount() != MaxHopCount {
+		t.Errorf("got hop count %d, want %d", got.HopCount(), MaxHopCount)
+	}
+}
+
+func TestDecodeRelayForwRejectsHopCountAboveMax(t *testing.T) {
+	relay := buildRelayForw(t, MaxHopCount+1)
+
+	if _, err := decodeRelayForw(relay.ToBytes()); err == nil {
+		t.Errorf("expected an error for a hop count above MaxHopCount")
+	}
+}
+
+func TestDecodeRelayForwRejectsNonRelayMessage(t *testing.T) {
+	data := []byte{byte(dhcpv6.SOLICIT), 0x00, 0x00, 0x01}
+
+	if _, err := decodeRelayForw(data); err == nil {
+		t.Errorf("expected an error for a non-relay message")
+	}
+}